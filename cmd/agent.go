@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+var runAsAgent = flag.Bool("vault-agent-mode", false, "Run as a long-lived Vault Agent style process: authenticate once, write configured token sinks, and keep configured templates rendered across renewals instead of exiting after a one-shot readKVSecrets.")
+
+// runAgent authenticates to Vault, projects the resulting token onto any
+// configured sinks, renders any configured templates, and then keeps both
+// the auth token and the templates fresh via the renewal scheduler until
+// ctx is cancelled. This is the long-running sidecar counterpart to the
+// one-shot authenticateToVault + readKVSecrets flow.
+func runAgent(ctx context.Context) error {
+	client, secret, err := authenticateToVault()
+	if err != nil {
+		return err
+	}
+
+	if sinks := sinksFromFlags(); len(sinks) > 0 {
+		if err := writeSinks(client, client.Token(), sinks); err != nil {
+			return err
+		}
+	}
+
+	templates, err := templatesFromFlags()
+	if err != nil {
+		return err
+	}
+	if len(templates) > 0 {
+		if err := renderTemplates(client, templates); err != nil {
+			return err
+		}
+	}
+
+	authTokenDuration, err := secret.TokenTTL()
+	if err != nil {
+		jww.DEBUG.Printf("Could not determine auth token TTL, scheduling it with a zero TTL: %v", err)
+	}
+
+	scheduler, cancel := startRenewalScheduler(ctx, client, authTokenDuration)
+	defer cancel()
+
+	if len(templates) > 0 {
+		watchAndRenderTemplates(ctx, client, scheduler, templates)
+	} else {
+		<-ctx.Done()
+	}
+
+	return nil
+}
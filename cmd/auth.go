@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hashicorp/vault/api"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+const (
+	authMethodToken      = "token"
+	authMethodKubernetes = "kubernetes"
+	authMethodAppRole    = "approle"
+	authMethodLDAP       = "ldap"
+	authMethodUserpass   = "userpass"
+	authMethodCert       = "cert"
+)
+
+var (
+	vaultAuthMethod    = flag.String("vault-auth-method", os.Getenv("VAULT_AUTH_METHOD"), "Vault auth method to use (token, kubernetes, approle, ldap, userpass, cert). Leave unset to use the default token/Kubernetes auto-detection.")
+	vaultAuthMountPath = flag.String("vault-auth-mount-path", os.Getenv("VAULT_AUTH_MOUNT_PATH"), "Mount path for the selected auth method, if non-default.")
+	appRoleID          = flag.String("vault-app-role", os.Getenv("VAULT_APP_ROLE"), "AppRole role_id to authenticate with.")
+	appRoleSecretID    = flag.String("vault-secret-id", os.Getenv("VAULT_SECRET_ID"), "AppRole secret_id to authenticate with.")
+	loginUser          = flag.String("vault-login-user", os.Getenv("VAULT_LOGIN_USER"), "Username for LDAP/userpass authentication.")
+	loginPassword      = flag.String("vault-login-password", os.Getenv("VAULT_LOGIN_PASSWORD"), "Password for LDAP/userpass authentication.")
+)
+
+// Authenticator logs into Vault using a single auth method and returns the
+// resulting client and the secret that carries the auth token, mirroring the
+// return shape of performKubernetesAuth/performTokenAuth.
+type Authenticator interface {
+	Authenticate(ctx context.Context) (*api.Client, *api.Secret, error)
+}
+
+func loginWithSecret(client *api.Client, path string, body map[string]interface{}) (*api.Secret, error) {
+	secret, err := client.Logical().Write(path, body)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("no response returned logging in at %q", path)
+	}
+
+	token, err := secret.TokenID()
+	if err != nil {
+		return nil, errwrapTokenID(path, err)
+	}
+	client.SetToken(token)
+
+	return secret, nil
+}
+
+func errwrapTokenID(path string, err error) error {
+	return fmt.Errorf("could not extract Vault token from login at %q: %w", path, err)
+}
+
+// AppRoleAuthenticator authenticates using the approle auth method, via
+// auth/<mount>/login with a role_id and secret_id.
+type AppRoleAuthenticator struct {
+	RoleID    string
+	SecretID  string
+	MountPath string
+}
+
+func (a *AppRoleAuthenticator) mountPath() string {
+	if a.MountPath != "" {
+		return a.MountPath
+	}
+	return "approle"
+}
+
+func (a *AppRoleAuthenticator) Authenticate(ctx context.Context) (*api.Client, *api.Secret, error) {
+	client, err := api.NewClient(api.DefaultConfig())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	path := fmt.Sprintf("auth/%s/login", a.mountPath())
+	jww.INFO.Printf("Authenticating to %q using AppRole auth at %q", client.Address(), path)
+
+	secret, err := loginWithSecret(client, path, map[string]interface{}{
+		"role_id":   a.RoleID,
+		"secret_id": a.SecretID,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, secret, nil
+}
+
+// LDAPAuthenticator authenticates using the ldap auth method, via
+// auth/<mount>/login/<username>.
+type LDAPAuthenticator struct {
+	Username  string
+	Password  string
+	MountPath string
+}
+
+func (a *LDAPAuthenticator) mountPath() string {
+	if a.MountPath != "" {
+		return a.MountPath
+	}
+	return "ldap"
+}
+
+func (a *LDAPAuthenticator) Authenticate(ctx context.Context) (*api.Client, *api.Secret, error) {
+	client, err := api.NewClient(api.DefaultConfig())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	path := fmt.Sprintf("auth/%s/login/%s", a.mountPath(), a.Username)
+	jww.INFO.Printf("Authenticating to %q as LDAP user %q", client.Address(), a.Username)
+
+	secret, err := loginWithSecret(client, path, map[string]interface{}{
+		"password": a.Password,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, secret, nil
+}
+
+// UserpassAuthenticator authenticates using the userpass auth method, via
+// auth/<mount>/login/<username>.
+type UserpassAuthenticator struct {
+	Username  string
+	Password  string
+	MountPath string
+}
+
+func (a *UserpassAuthenticator) mountPath() string {
+	if a.MountPath != "" {
+		return a.MountPath
+	}
+	return "userpass"
+}
+
+func (a *UserpassAuthenticator) Authenticate(ctx context.Context) (*api.Client, *api.Secret, error) {
+	client, err := api.NewClient(api.DefaultConfig())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	path := fmt.Sprintf("auth/%s/login/%s", a.mountPath(), a.Username)
+	jww.INFO.Printf("Authenticating to %q as userpass user %q", client.Address(), a.Username)
+
+	secret, err := loginWithSecret(client, path, map[string]interface{}{
+		"password": a.Password,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, secret, nil
+}
+
+// CertAuthenticator authenticates using the cert auth method. The client
+// certificate itself is supplied via the usual VAULT_CLIENT_CERT/
+// VAULT_CLIENT_KEY environment variables, which api.DefaultConfig already
+// digests into the client's TLS config.
+type CertAuthenticator struct {
+	MountPath string
+}
+
+func (a *CertAuthenticator) mountPath() string {
+	if a.MountPath != "" {
+		return a.MountPath
+	}
+	return "cert"
+}
+
+func (a *CertAuthenticator) Authenticate(ctx context.Context) (*api.Client, *api.Secret, error) {
+	client, err := api.NewClient(api.DefaultConfig())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	path := fmt.Sprintf("auth/%s/login", a.mountPath())
+	jww.INFO.Printf("Authenticating to %q using TLS client cert auth at %q", client.Address(), path)
+
+	secret, err := loginWithSecret(client, path, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, secret, nil
+}
+
+// KubernetesAuthenticator adapts the existing Kubernetes service account
+// login flow to the Authenticator interface.
+type KubernetesAuthenticator struct{}
+
+func (a *KubernetesAuthenticator) Authenticate(ctx context.Context) (*api.Client, *api.Secret, error) {
+	return cachedKubernetesAuth()
+}
+
+// selectAuthenticator builds the Authenticator for *vaultAuthMethod, if one
+// was explicitly requested via --vault-auth-method/VAULT_AUTH_METHOD.
+// It returns a nil Authenticator when no method was selected or when "token"
+// was selected, in which case
+// callers should fall back to the legacy token/ConfigMap/Kubernetes probing
+// in authenticateToVault.
+func selectAuthenticator() (Authenticator, error) {
+	switch *vaultAuthMethod {
+	case "":
+		return nil, nil
+	case authMethodToken:
+		return nil, nil
+	case authMethodKubernetes:
+		return &KubernetesAuthenticator{}, nil
+	case authMethodAppRole:
+		return &AppRoleAuthenticator{RoleID: *appRoleID, SecretID: *appRoleSecretID, MountPath: *vaultAuthMountPath}, nil
+	case authMethodLDAP:
+		return &LDAPAuthenticator{Username: *loginUser, Password: *loginPassword, MountPath: *vaultAuthMountPath}, nil
+	case authMethodUserpass:
+		return &UserpassAuthenticator{Username: *loginUser, Password: *loginPassword, MountPath: *vaultAuthMountPath}, nil
+	case authMethodCert:
+		return &CertAuthenticator{MountPath: *vaultAuthMountPath}, nil
+	default:
+		return nil, fmt.Errorf("unknown vault auth method %q", *vaultAuthMethod)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// kvMount describes the secrets engine mount backing a given path, as
+// reported by Vault itself rather than guessed from the path's shape.
+type kvMount struct {
+	// Path is the mount's own path, e.g. "team/kv/", exactly as Vault
+	// returns it - which may itself contain slashes.
+	Path    string
+	Version int
+}
+
+// lookupKVMount asks Vault's internal mounts UI endpoint which secrets
+// engine backs path, and what KV version it is. This is the same
+// preflight check the official Vault CLI's KV v2 support uses, and is the
+// only reliable way to find the mount boundary: it can't be re-derived by
+// splitting path on its first "/", since a mount itself may contain
+// slashes (e.g. "team/kv/").
+func lookupKVMount(client *api.Client, path string) (kvMount, error) {
+	secret, err := client.Logical().Read("sys/internal/ui/mounts/" + strings.TrimPrefix(path, "/"))
+	if err != nil {
+		return kvMount{}, err
+	}
+	if secret == nil {
+		return kvMount{}, fmt.Errorf("no response looking up the mount for %q", path)
+	}
+
+	mountPath, ok := secret.Data["path"].(string)
+	if !ok || mountPath == "" {
+		return kvMount{}, fmt.Errorf("mount lookup for %q did not return a mount path", path)
+	}
+
+	version := 1
+	if options, ok := secret.Data["options"].(map[string]interface{}); ok {
+		if v, ok := options["version"].(string); ok && v == "2" {
+			version = 2
+		}
+	}
+
+	return kvMount{Path: strings.Trim(mountPath, "/"), Version: version}, nil
+}
+
+// kvDataPath rewrites a KV v2 path to route through its data/ prefix, given
+// the mount's own path as reported by Vault, e.g. mount "team/kv" and path
+// "team/kv/foo" becomes "team/kv/data/foo".
+func kvDataPath(mountPath string, path string) string {
+	relative := strings.TrimPrefix(strings.TrimPrefix(path, "/"), mountPath+"/")
+	return fmt.Sprintf("%s/data/%s", mountPath, relative)
+}
+
+// readSecret fetches a secret from path, transparently handling KV v2's
+// data/ envelope. kvVersion may be "1" or "2" to force a version rather than
+// auto-detecting via the mounts API; an empty string auto-detects. version,
+// if non-zero, pins a specific KV v2 secret version.
+func readSecret(client *api.Client, path string, kvVersion string, version int) (*api.Secret, error) {
+	if kvVersion == "1" {
+		return client.Logical().Read(path)
+	}
+
+	mount, err := lookupKVMount(client, path)
+	if err != nil {
+		if kvVersion == "2" {
+			return nil, fmt.Errorf("could not determine KV v2 mount path for %q: %w", path, err)
+		}
+		jww.DEBUG.Printf("Could not determine KV mount version for %q, assuming v1: %v", path, err)
+		return client.Logical().Read(path)
+	}
+
+	if kvVersion != "2" && mount.Version != 2 {
+		return client.Logical().Read(path)
+	}
+
+	dataPath := kvDataPath(mount.Path, path)
+
+	var params map[string][]string
+	if version != 0 {
+		params = map[string][]string{"version": {strconv.Itoa(version)}}
+	}
+
+	response, err := client.Logical().ReadWithData(dataPath, params)
+	if err != nil || response == nil {
+		return response, err
+	}
+
+	return unwrapKVv2(response), nil
+}
+
+// unwrapKVv2 flattens a KV v2 envelope ({"data": {...}, "metadata": {...}})
+// into a secret whose Data looks like the v1 shape callers already expect.
+// metadata.version is logged at debug level only; the returned secret does
+// not carry the metadata.
+func unwrapKVv2(secret *api.Secret) *api.Secret {
+	data, ok := secret.Data["data"].(map[string]interface{})
+	if !ok {
+		return secret
+	}
+
+	unwrapped := *secret
+	unwrapped.Data = data
+
+	if metadata, ok := secret.Data["metadata"].(map[string]interface{}); ok {
+		if version, ok := metadata["version"]; ok {
+			jww.DEBUG.Printf("Read KV v2 secret version %v", version)
+		}
+	}
+
+	return &unwrapped
+}
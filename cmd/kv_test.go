@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+)
+
+func TestKVDataPath(t *testing.T) {
+	cases := []struct {
+		name      string
+		mountPath string
+		path      string
+		want      string
+	}{
+		{
+			name:      "single-segment mount",
+			mountPath: "secret",
+			path:      "secret/foo",
+			want:      "secret/data/foo",
+		},
+		{
+			name:      "nested mount",
+			mountPath: "team/kv",
+			path:      "team/kv/foo/bar",
+			want:      "team/kv/data/foo/bar",
+		},
+		{
+			name:      "leading slash on path",
+			mountPath: "secret",
+			path:      "/secret/foo",
+			want:      "secret/data/foo",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := kvDataPath(c.mountPath, c.path); got != c.want {
+				t.Errorf("kvDataPath(%q, %q) = %q, want %q", c.mountPath, c.path, got, c.want)
+			}
+		})
+	}
+}
+
+func TestUnwrapKVv2(t *testing.T) {
+	secret := &api.Secret{
+		Data: map[string]interface{}{
+			"data": map[string]interface{}{
+				"username": "alice",
+			},
+			"metadata": map[string]interface{}{
+				"version": 3,
+			},
+		},
+	}
+
+	unwrapped := unwrapKVv2(secret)
+
+	if unwrapped.Data["username"] != "alice" {
+		t.Errorf("unwrapped.Data = %v, want username=alice", unwrapped.Data)
+	}
+}
+
+func TestUnwrapKVv2PassesThroughNonEnvelopeData(t *testing.T) {
+	secret := &api.Secret{
+		Data: map[string]interface{}{
+			"username": "alice",
+		},
+	}
+
+	unwrapped := unwrapKVv2(secret)
+
+	if unwrapped.Data["username"] != "alice" {
+		t.Errorf("unwrapped.Data = %v, want unchanged", unwrapped.Data)
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+)
+
+// Execute is the tool's single entrypoint, called by main(). It dispatches
+// to the long-running Vault Agent style mode when --vault-agent-mode is
+// set, and otherwise runs the original one-shot authenticate-then-fetch
+// flow.
+//
+// The one-shot flow deliberately does not start a RenewalScheduler: leases
+// fetched here are renewed at ~80% of their TTL, long after this process
+// has already exited, so a scheduler here would just be started and
+// immediately cancelled for nothing. Proactive background renewal is only
+// available in --vault-agent-mode, where the process actually stays alive
+// long enough to use it.
+func Execute() error {
+	if *runAsAgent {
+		return runAgent(context.Background())
+	}
+
+	client, _, err := authenticateToVault()
+	if err != nil {
+		return err
+	}
+
+	readKVSecrets(client)
+
+	return nil
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/hashicorp/vault/api"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// activeScheduler is the RenewalScheduler started for the current process,
+// if any. renewSelf and readKVSecrets feed their leases into it so that,
+// once it exists, renewal happens proactively in the background instead of
+// only on the next explicit call.
+var activeScheduler *RenewalScheduler
+
+// renewalWindowGuard is how close to a lease's final expiry we'll get before
+// giving up on renewing it and instead reporting it as expired.
+const renewalWindowGuard = 30 * time.Second
+
+// renewalJitter bounds the random jitter added to each lease's renewal
+// deadline, to avoid a thundering herd of renewals firing at once.
+const renewalJitter = 10 * time.Second
+
+// scheduledLease tracks a single Vault lease (or the auth token itself)
+// that the RenewalScheduler is responsible for keeping alive.
+type scheduledLease struct {
+	ID          string
+	IsAuthToken bool
+	Increment   time.Duration
+	ExpiresAt   time.Time
+	RenewAfter  time.Time
+	index       int // maintained by container/heap
+}
+
+// leaseHeap is a min-heap of scheduledLeases ordered by RenewAfter.
+type leaseHeap []*scheduledLease
+
+func (h leaseHeap) Len() int            { return len(h) }
+func (h leaseHeap) Less(i, j int) bool  { return h[i].RenewAfter.Before(h[j].RenewAfter) }
+func (h leaseHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i]; h[i].index = i; h[j].index = j }
+func (h *leaseHeap) Push(x interface{}) {
+	lease := x.(*scheduledLease)
+	lease.index = len(*h)
+	*h = append(*h, lease)
+}
+func (h *leaseHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	lease := old[n-1]
+	old[n-1] = nil
+	lease.index = -1
+	*h = old[:n-1]
+	return lease
+}
+
+// RenewalEventType distinguishes the outcomes a RenewalScheduler reports on
+// its events channel.
+type RenewalEventType int
+
+const (
+	RenewalSucceeded RenewalEventType = iota
+	RenewalFailed
+	RenewalExpired
+)
+
+// RenewalEvent is emitted for every lease the scheduler renews, fails to
+// renew, or gives up on. Callers watching for RenewalFailed/RenewalExpired
+// on a dynamic secret's lease ID should treat it as a signal to re-fetch
+// that secret rather than waiting for it to expire outright.
+type RenewalEvent struct {
+	LeaseID     string
+	IsAuthToken bool
+	Type        RenewalEventType
+	Err         error
+}
+
+// RenewalScheduler renews Vault leases (and the auth token itself) shortly
+// before they expire, using a min-heap keyed on renewal deadline so the
+// background goroutine only wakes when there's actual work to do.
+type RenewalScheduler struct {
+	client *api.Client
+
+	mu   sync.Mutex
+	heap leaseHeap
+	byID map[string]*scheduledLease
+	wake chan struct{}
+
+	subMu       sync.Mutex
+	subscribers []chan RenewalEvent
+}
+
+// NewRenewalScheduler creates a scheduler that renews leases against client.
+// Callers must invoke Run in a goroutine to start processing.
+func NewRenewalScheduler(client *api.Client) *RenewalScheduler {
+	return &RenewalScheduler{
+		client: client,
+		byID:   make(map[string]*scheduledLease),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Subscribe returns a new channel that receives the RenewalEvents the
+// scheduler publishes from now on. Every subscriber gets its own copy of
+// each event, so multiple independent consumers (e.g. a logger and a
+// template re-renderer) can watch the same scheduler without stealing
+// events from one another. An event is dropped for any subscriber whose
+// 16-event buffer is full when it is published.
+func (s *RenewalScheduler) Subscribe() <-chan RenewalEvent {
+	ch := make(chan RenewalEvent, 16)
+
+	s.subMu.Lock()
+	s.subscribers = append(s.subscribers, ch)
+	s.subMu.Unlock()
+
+	return ch
+}
+
+func (s *RenewalScheduler) publish(event RenewalEvent) {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+
+	for _, ch := range s.subscribers {
+		select {
+		case ch <- event:
+		default:
+			jww.ERROR.Printf("Renewal event subscriber is falling behind; dropping event for lease %q.", event.LeaseID)
+		}
+	}
+}
+
+// AddLease registers (or re-registers) a lease for renewal at ~80% of its
+// duration, plus a small jitter to avoid a thundering herd.
+func (s *RenewalScheduler) AddLease(leaseID string, isAuthToken bool, duration time.Duration) {
+	renewAfter := time.Now().
+		Add(time.Duration(float64(duration) * 0.8)).
+		Add(time.Duration(rand.Int63n(int64(renewalJitter))))
+
+	lease := &scheduledLease{
+		ID:          leaseID,
+		IsAuthToken: isAuthToken,
+		Increment:   duration,
+		ExpiresAt:   time.Now().Add(duration),
+		RenewAfter:  renewAfter,
+	}
+
+	s.mu.Lock()
+	if existing, ok := s.byID[leaseID]; ok {
+		heap.Remove(&s.heap, existing.index)
+	}
+	heap.Push(&s.heap, lease)
+	s.byID[leaseID] = lease
+	s.mu.Unlock()
+
+	s.nudge()
+}
+
+// RemoveLease drops a lease from the scheduler, e.g. once it has been
+// explicitly revoked.
+func (s *RenewalScheduler) RemoveLease(leaseID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lease, ok := s.byID[leaseID]
+	if !ok {
+		return
+	}
+	heap.Remove(&s.heap, lease.index)
+	delete(s.byID, leaseID)
+}
+
+func (s *RenewalScheduler) nudge() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// nextWait returns how long to sleep before the next lease is due for
+// renewal, and whether there is a lease at all.
+func (s *RenewalScheduler) nextWait() (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.heap.Len() == 0 {
+		return 0, false
+	}
+	return time.Until(s.heap[0].RenewAfter), true
+}
+
+func (s *RenewalScheduler) popDue() *scheduledLease {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.heap.Len() == 0 {
+		return nil
+	}
+	if s.heap[0].RenewAfter.After(time.Now()) {
+		return nil
+	}
+
+	lease := heap.Pop(&s.heap).(*scheduledLease)
+	delete(s.byID, lease.ID)
+	return lease
+}
+
+// Run processes the renewal schedule until ctx is cancelled. It is meant to
+// be started as a single long-lived goroutine.
+func (s *RenewalScheduler) Run(ctx context.Context) {
+	for {
+		wait, ok := s.nextWait()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.wake:
+				continue
+			}
+		}
+
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-s.wake:
+				timer.Stop()
+				continue
+			case <-timer.C:
+			}
+		}
+
+		for lease := s.popDue(); lease != nil; lease = s.popDue() {
+			s.renew(ctx, lease)
+		}
+	}
+}
+
+func (s *RenewalScheduler) renew(ctx context.Context, lease *scheduledLease) {
+	if time.Until(lease.ExpiresAt) <= renewalWindowGuard {
+		jww.INFO.Printf("Lease %q is within its final expiry window; skipping renewal.", lease.ID)
+		s.publish(RenewalEvent{LeaseID: lease.ID, IsAuthToken: lease.IsAuthToken, Type: RenewalExpired})
+		return
+	}
+
+	var renewed *api.Secret
+
+	op := func() error {
+		var err error
+		if lease.IsAuthToken {
+			renewed, err = s.client.Auth().Token().RenewSelf(int(lease.Increment.Seconds()))
+		} else {
+			renewed, err = s.client.Sys().Renew(lease.ID, int(lease.Increment.Seconds()))
+		}
+
+		if err != nil {
+			if checkPermissionDenied(err) {
+				return backoff.Permanent(ErrPermissionDenied)
+			}
+			return err
+		}
+		return nil
+	}
+
+	err := backoff.Retry(op, backoff.WithContext(defaultRetryStrategy(lease.Increment), ctx))
+	if err != nil {
+		jww.ERROR.Printf("Failed to renew lease %q: %v", lease.ID, err)
+		s.publish(RenewalEvent{LeaseID: lease.ID, IsAuthToken: lease.IsAuthToken, Type: RenewalFailed, Err: err})
+		return
+	}
+
+	jww.INFO.Printf("Renewed lease %q.", lease.ID)
+	s.AddLease(lease.ID, lease.IsAuthToken, grantedDuration(lease, renewed))
+	s.publish(RenewalEvent{LeaseID: lease.ID, IsAuthToken: lease.IsAuthToken, Type: RenewalSucceeded})
+}
+
+// grantedDuration returns the lease duration Vault actually granted on
+// renewal, falling back to the originally requested increment if the
+// response didn't carry one. Vault commonly caps a renewal short of what
+// was asked for (e.g. near a lease's max_ttl), so rescheduling off of the
+// requested increment instead of the granted one would drift the
+// scheduler's bookkeeping away from the lease's real expiry.
+func grantedDuration(lease *scheduledLease, renewed *api.Secret) time.Duration {
+	if renewed == nil {
+		return lease.Increment
+	}
+
+	if lease.IsAuthToken {
+		if renewed.Auth != nil && renewed.Auth.LeaseDuration > 0 {
+			return time.Duration(renewed.Auth.LeaseDuration) * time.Second
+		}
+		return lease.Increment
+	}
+
+	if renewed.LeaseDuration > 0 {
+		return time.Duration(renewed.LeaseDuration) * time.Second
+	}
+	return lease.Increment
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"container/heap"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+func TestLeaseHeapOrdersByRenewAfter(t *testing.T) {
+	now := time.Now()
+	h := &leaseHeap{}
+
+	leases := []*scheduledLease{
+		{ID: "third", RenewAfter: now.Add(30 * time.Minute)},
+		{ID: "first", RenewAfter: now.Add(5 * time.Minute)},
+		{ID: "second", RenewAfter: now.Add(15 * time.Minute)},
+	}
+	for _, lease := range leases {
+		heap.Push(h, lease)
+	}
+
+	var popped []string
+	for h.Len() > 0 {
+		popped = append(popped, heap.Pop(h).(*scheduledLease).ID)
+	}
+
+	want := []string{"first", "second", "third"}
+	if len(popped) != len(want) {
+		t.Fatalf("popped %v, want %v", popped, want)
+	}
+	for i := range want {
+		if popped[i] != want[i] {
+			t.Errorf("pop order[%d] = %q, want %q (full order: %v)", i, popped[i], want[i], popped)
+		}
+	}
+}
+
+func TestLeaseHeapRemove(t *testing.T) {
+	now := time.Now()
+	h := &leaseHeap{}
+
+	a := &scheduledLease{ID: "a", RenewAfter: now.Add(1 * time.Minute)}
+	b := &scheduledLease{ID: "b", RenewAfter: now.Add(2 * time.Minute)}
+	c := &scheduledLease{ID: "c", RenewAfter: now.Add(3 * time.Minute)}
+	heap.Push(h, a)
+	heap.Push(h, b)
+	heap.Push(h, c)
+
+	heap.Remove(h, b.index)
+
+	if h.Len() != 2 {
+		t.Fatalf("heap len = %d, want 2", h.Len())
+	}
+	remaining := map[string]bool{}
+	for _, lease := range *h {
+		remaining[lease.ID] = true
+	}
+	if remaining["b"] {
+		t.Errorf("removed lease %q still present in heap", "b")
+	}
+	if !remaining["a"] || !remaining["c"] {
+		t.Errorf("heap lost an unrelated lease: %v", remaining)
+	}
+}
+
+func TestAddLeaseSchedulesWithinJitterWindow(t *testing.T) {
+	s := NewRenewalScheduler(nil)
+
+	duration := 100 * time.Second
+	before := time.Now()
+	s.AddLease("lease-1", false, duration)
+	after := time.Now()
+
+	s.mu.Lock()
+	lease, ok := s.byID["lease-1"]
+	s.mu.Unlock()
+	if !ok {
+		t.Fatalf("lease-1 not registered in scheduler")
+	}
+
+	minRenewAfter := before.Add(time.Duration(float64(duration) * 0.8))
+	maxRenewAfter := after.Add(time.Duration(float64(duration) * 0.8)).Add(renewalJitter)
+
+	if lease.RenewAfter.Before(minRenewAfter) || lease.RenewAfter.After(maxRenewAfter) {
+		t.Errorf("RenewAfter = %v, want between %v and %v", lease.RenewAfter, minRenewAfter, maxRenewAfter)
+	}
+}
+
+func TestGrantedDurationFallsBackWithoutResponse(t *testing.T) {
+	lease := &scheduledLease{Increment: 42 * time.Second}
+
+	if got := grantedDuration(lease, nil); got != lease.Increment {
+		t.Errorf("grantedDuration(nil) = %v, want %v", got, lease.Increment)
+	}
+}
+
+func TestGrantedDurationUsesAuthLeaseDurationForAuthTokens(t *testing.T) {
+	lease := &scheduledLease{IsAuthToken: true, Increment: 42 * time.Second}
+	renewed := &api.Secret{Auth: &api.SecretAuth{LeaseDuration: 7}}
+
+	got := grantedDuration(lease, renewed)
+	want := 7 * time.Second
+	if got != want {
+		t.Errorf("grantedDuration = %v, want %v", got, want)
+	}
+}
+
+func TestGrantedDurationFallsBackWhenAuthMissingLeaseDuration(t *testing.T) {
+	lease := &scheduledLease{IsAuthToken: true, Increment: 42 * time.Second}
+	renewed := &api.Secret{}
+
+	if got := grantedDuration(lease, renewed); got != lease.Increment {
+		t.Errorf("grantedDuration = %v, want fallback %v", got, lease.Increment)
+	}
+}
+
+func TestGrantedDurationUsesLeaseDurationForDynamicSecrets(t *testing.T) {
+	lease := &scheduledLease{Increment: 42 * time.Second}
+	renewed := &api.Secret{LeaseDuration: 9}
+
+	got := grantedDuration(lease, renewed)
+	want := 9 * time.Second
+	if got != want {
+		t.Errorf("grantedDuration = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+var (
+	tokenSinksFlag = flag.String("vault-token-sink", "", "Comma-separated list of file paths to write the Vault auth token to after authentication, Vault Agent style.")
+	tokenSinkWrap  = flag.Bool("vault-token-sink-wrap", false, "Wrap the token written to sink files via sys/wrapping/wrap before writing.")
+	tokenSinkMode  = flag.Uint("vault-token-sink-mode", 0600, "File mode bits to create token sink files with.")
+)
+
+// Sink is a single destination that the authenticated Vault token is
+// projected onto, mirroring Vault Agent's auto-auth sink concept.
+type Sink struct {
+	Path string
+	Mode os.FileMode
+	Wrap bool
+}
+
+// sinksFromFlags builds the Sink list from --vault-token-sink, applying the
+// shared --vault-token-sink-wrap/--vault-token-sink-mode settings to each.
+func sinksFromFlags() []Sink {
+	if *tokenSinksFlag == "" {
+		return nil
+	}
+
+	var sinks []Sink
+	for _, path := range strings.Split(*tokenSinksFlag, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		sinks = append(sinks, Sink{
+			Path: path,
+			Mode: os.FileMode(*tokenSinkMode),
+			Wrap: *tokenSinkWrap,
+		})
+	}
+	return sinks
+}
+
+// writeSinks projects token onto every configured sink, optionally response
+// wrapping it first via sys/wrapping/wrap.
+func writeSinks(client *api.Client, token string, sinks []Sink) error {
+	for _, sink := range sinks {
+		value := token
+		if sink.Wrap {
+			wrapped, err := wrapToken(client, token)
+			if err != nil {
+				return fmt.Errorf("failed to wrap token for sink %q: %w", sink.Path, err)
+			}
+			value = wrapped
+		}
+
+		mode := sink.Mode
+		if mode == 0 {
+			mode = 0600
+		}
+
+		jww.INFO.Printf("Writing Vault token sink to %q", sink.Path)
+		if err := ioutil.WriteFile(sink.Path, []byte(value), mode); err != nil {
+			return fmt.Errorf("failed to write sink %q: %w", sink.Path, err)
+		}
+	}
+	return nil
+}
+
+// wrapToken response-wraps token via sys/wrapping/wrap and returns the
+// resulting single-use wrapping token.
+func wrapToken(client *api.Client, token string) (string, error) {
+	wrappingClient, err := client.Clone()
+	if err != nil {
+		return "", err
+	}
+	wrappingClient.SetToken(client.Token())
+	wrappingClient.SetWrappingLookupFunc(func(operation, path string) string {
+		return "24h"
+	})
+
+	secret, err := wrappingClient.Logical().Write("sys/wrapping/wrap", map[string]interface{}{"token": token})
+	if err != nil {
+		return "", err
+	}
+	if secret == nil || secret.WrapInfo == nil {
+		return "", fmt.Errorf("no wrap info returned wrapping token")
+	}
+
+	return secret.WrapInfo.Token, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/hashicorp/vault/api"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+var (
+	templatesFlag    = flag.String("vault-template", "", "Comma-separated list of src:dest pairs of Go templates to render secrets into, re-rendered on every lease renewal.")
+	templateFileMode = flag.Uint("vault-template-mode", 0600, "File mode bits to create rendered template files with.")
+)
+
+// Template describes a single Go-template source that gets rendered to a
+// destination file every time the renewal scheduler ticks, Vault Agent
+// style.
+type Template struct {
+	Source      string
+	Destination string
+	Mode        os.FileMode
+}
+
+// templatesFromFlags parses --vault-template src:dest[,src:dest...] into a
+// Template list.
+func templatesFromFlags() ([]Template, error) {
+	if *templatesFlag == "" {
+		return nil, nil
+	}
+
+	var templates []Template
+	for _, pair := range strings.Split(*templatesFlag, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid --vault-template entry %q, expected src:dest", pair)
+		}
+
+		templates = append(templates, Template{
+			Source:      parts[0],
+			Destination: parts[1],
+			Mode:        os.FileMode(*templateFileMode),
+		})
+	}
+	return templates, nil
+}
+
+// secretTemplateFuncs exposes a `secret` function to templates, matching
+// Vault Agent's template syntax: {{ with secret "kv/data/foo" }}{{
+// .Data.data.password }}{{ end }}.
+func secretTemplateFuncs(client *api.Client) template.FuncMap {
+	return template.FuncMap{
+		"secret": func(path string) (*api.Secret, error) {
+			return client.Logical().Read(path)
+		},
+	}
+}
+
+// renderTemplate executes a single template against live Vault data and
+// writes the result to its destination.
+func renderTemplate(client *api.Client, tmpl Template) error {
+	raw, err := ioutil.ReadFile(tmpl.Source)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := template.New(filepath.Base(tmpl.Source)).Funcs(secretTemplateFuncs(client)).Parse(string(raw))
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := parsed.Execute(&buf, nil); err != nil {
+		return err
+	}
+
+	mode := tmpl.Mode
+	if mode == 0 {
+		mode = 0600
+	}
+
+	jww.INFO.Printf("Rendering template %q to %q", tmpl.Source, tmpl.Destination)
+	return ioutil.WriteFile(tmpl.Destination, buf.Bytes(), mode)
+}
+
+// renderTemplates renders every configured template once, e.g. right after
+// initial authentication.
+func renderTemplates(client *api.Client, templates []Template) error {
+	for _, tmpl := range templates {
+		if err := renderTemplate(client, tmpl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watchAndRenderTemplates re-renders every template each time the renewal
+// scheduler reports a successful renewal, turning readKVSecrets' one-shot
+// fetch into a continuously projected view of secrets on disk. It runs
+// until ctx is cancelled.
+func watchAndRenderTemplates(ctx context.Context, client *api.Client, scheduler *RenewalScheduler, templates []Template) {
+	events := scheduler.Subscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Type != RenewalSucceeded {
+				continue
+			}
+			if err := renderTemplates(client, templates); err != nil {
+				jww.ERROR.Printf("Failed to re-render templates after renewal: %v", err)
+			}
+		}
+	}
+}
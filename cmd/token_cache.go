@@ -0,0 +1,126 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+var (
+	tokenCacheFile = flag.String("token-cache-file", "", "Path to a file used to cache the Vault token across invocations, avoiding a fresh Kubernetes login (and a fresh token in Vault's store) on every run.")
+	forceReauth    = flag.Bool("force-reauth", false, "Ignore any cached or lease-file token and force a fresh Kubernetes auth login.")
+)
+
+// tokenCacheHits/tokenCacheMisses let operators confirm via logs that the
+// token cache is actually cutting down on token creation rate.
+var (
+	tokenCacheHits   int
+	tokenCacheMisses int
+)
+
+// cachedKubernetesAuth wraps performKubernetesAuth with a token cache: it
+// first tries the lease file's token, then --token-cache-file, and only
+// falls through to a fresh Kubernetes login (which mints a brand new Vault
+// token every time) when neither is usable. This keeps repeated
+// invocations - e.g. init containers or cron sidecars calling this tool on
+// every run - from flooding Vault's token store with one-shot tokens.
+func cachedKubernetesAuth() (*api.Client, *api.Secret, error) {
+	if *forceReauth {
+		jww.INFO.Print("--force-reauth set, skipping token cache lookup.")
+		return reauthAndCacheKubernetes()
+	}
+
+	if leases.AuthTokenLease.Token != "" {
+		if client, secret, ok := tryCachedToken(leases.AuthTokenLease.Token, "lease file"); ok {
+			return client, secret, nil
+		}
+	}
+
+	if *tokenCacheFile != "" {
+		token, err := readTokenCacheFile(*tokenCacheFile)
+		if err != nil {
+			jww.DEBUG.Printf("Could not read token cache file %q: %v", *tokenCacheFile, err)
+		} else if token != "" {
+			if client, secret, ok := tryCachedToken(token, "cache file"); ok {
+				return client, secret, nil
+			}
+		}
+	}
+
+	tokenCacheMisses++
+	jww.INFO.Printf("No valid cached token found (cache hits: %d, misses: %d); authenticating fresh.", tokenCacheHits, tokenCacheMisses)
+
+	return reauthAndCacheKubernetes()
+}
+
+// tryCachedToken verifies a candidate token is still valid by looking it up
+// against Vault before committing to reuse it.
+func tryCachedToken(token string, source string) (*api.Client, *api.Secret, bool) {
+	client, secret, err := performTokenAuth(api.DefaultConfig(), token)
+	if err != nil {
+		jww.DEBUG.Printf("Cached token from %s is no longer valid: %v", source, err)
+		return nil, nil, false
+	}
+
+	tokenCacheHits++
+	jww.INFO.Printf("Reusing still-valid Vault token from %s (cache hits: %d, misses: %d).", source, tokenCacheHits, tokenCacheMisses)
+	return client, secret, true
+}
+
+func reauthAndCacheKubernetes() (*api.Client, *api.Secret, error) {
+	client, secret, err := performKubernetesAuth()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if *tokenCacheFile != "" {
+		if err := writeTokenCacheFile(*tokenCacheFile, client.Token()); err != nil {
+			jww.ERROR.Printf("Failed to persist token cache file %q: %v", *tokenCacheFile, err)
+		}
+	}
+
+	return client, secret, nil
+}
+
+func readTokenCacheFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// writeTokenCacheFile writes token to path atomically - write to a temp
+// file in the same directory, then rename - with strict 0600 perms, so
+// sibling processes sharing the cache file never observe a partial write.
+func writeTokenCacheFile(path string, token string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".token-cache-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.WriteString(token); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, 0600); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteReadTokenCacheFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token-cache")
+
+	if err := writeTokenCacheFile(path, "s.abc123"); err != nil {
+		t.Fatalf("writeTokenCacheFile: %v", err)
+	}
+
+	got, err := readTokenCacheFile(path)
+	if err != nil {
+		t.Fatalf("readTokenCacheFile: %v", err)
+	}
+	if got != "s.abc123" {
+		t.Errorf("readTokenCacheFile = %q, want %q", got, "s.abc123")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("cache file perms = %v, want 0600", perm)
+	}
+}
+
+func TestWriteTokenCacheFileOverwritesExisting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token-cache")
+
+	if err := writeTokenCacheFile(path, "first"); err != nil {
+		t.Fatalf("writeTokenCacheFile: %v", err)
+	}
+	if err := writeTokenCacheFile(path, "second"); err != nil {
+		t.Fatalf("writeTokenCacheFile: %v", err)
+	}
+
+	got, err := readTokenCacheFile(path)
+	if err != nil {
+		t.Fatalf("readTokenCacheFile: %v", err)
+	}
+	if got != "second" {
+		t.Errorf("readTokenCacheFile = %q, want %q", got, "second")
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.Name() != filepath.Base(path) {
+			t.Errorf("leftover temp file in cache dir: %q", entry.Name())
+		}
+	}
+}
+
+func TestReadTokenCacheFileMissingReturnsEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "does-not-exist")
+
+	got, err := readTokenCacheFile(path)
+	if err != nil {
+		t.Fatalf("readTokenCacheFile: %v", err)
+	}
+	if got != "" {
+		t.Errorf("readTokenCacheFile = %q, want empty string for missing file", got)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -22,6 +23,12 @@ import (
 
 var ErrPermissionDenied = errors.New("permission denied")
 
+var (
+	vaultTokenConfigMapNamespace = flag.String("vault-token-configmap-namespace", "default", "Namespace of the ConfigMap to look for a Vault token in.")
+	vaultTokenConfigMapName      = flag.String("vault-token-configmap-name", "vault-token", "Name of the ConfigMap to look for a Vault token in.")
+	vaultTokenConfigMapKey       = flag.String("vault-token-configmap-key", "token", "Data key within the ConfigMap that holds the Vault token.")
+)
+
 func defaultRetryStrategy(max time.Duration) backoff.BackOff {
 	strategy := backoff.NewExponentialBackOff()
 	strategy.InitialInterval = time.Millisecond * 500
@@ -52,6 +59,13 @@ func renewSelf(ctx context.Context, client *api.Client, duration time.Duration)
 		jww.INFO.Print("Vault authentication token renewed.")
 		enrollAuthTokenInLease(secret)
 
+		// Hand the token off to the background scheduler, if one is
+		// running, so subsequent renewals happen proactively instead of
+		// requiring another explicit renewSelf call.
+		if activeScheduler != nil {
+			activeScheduler.AddLease(client.Token(), true, duration)
+		}
+
 		return nil
 	}
 
@@ -60,6 +74,34 @@ func renewSelf(ctx context.Context, client *api.Client, duration time.Duration)
 	return err
 }
 
+// startRenewalScheduler launches a background RenewalScheduler for client
+// and hands back both the scheduler and a cancel func. The auth token's own
+// lease is registered immediately; callers enrolling dynamic secret leases
+// (e.g. via enrollSecretInLease) should also AddLease on the returned
+// scheduler so they get renewed proactively. renewSelf above renews only
+// the auth token, never dynamic secret leases.
+func startRenewalScheduler(ctx context.Context, client *api.Client, authTokenDuration time.Duration) (*RenewalScheduler, context.CancelFunc) {
+	schedulerCtx, cancel := context.WithCancel(ctx)
+
+	scheduler := NewRenewalScheduler(client)
+	scheduler.AddLease(client.Token(), true, authTokenDuration)
+	activeScheduler = scheduler
+
+	logEvents := scheduler.Subscribe()
+
+	go scheduler.Run(schedulerCtx)
+	go func() {
+		for event := range logEvents {
+			switch event.Type {
+			case RenewalFailed, RenewalExpired:
+				jww.ERROR.Printf("Lease %q could not be kept alive (authToken=%v): %v", event.LeaseID, event.IsAuthToken, event.Err)
+			}
+		}
+	}()
+
+	return scheduler, cancel
+}
+
 func performKubernetesAuth() (*api.Client, *api.Secret, error) {
 	type login struct {
 		JWT  string `json:"jwt"`
@@ -69,7 +111,8 @@ func performKubernetesAuth() (*api.Client, *api.Secret, error) {
 	cfg := api.DefaultConfig()
 	client, err := api.NewClient(cfg)
 	if err != nil {
-		jww.FATAL.Fatalf("Failed to create vault client to %q: %v", client.Address(), err)
+		// client is nil here, so it must not be dereferenced (e.g. via client.Address()).
+		return nil, nil, fmt.Errorf("failed to create vault client to %q: %w", cfg.Address, err)
 	}
 
 	jww.INFO.Printf("Reading Kubernetes service account token: %q", *serviceAccountToken)
@@ -103,7 +146,7 @@ func performKubernetesAuth() (*api.Client, *api.Secret, error) {
 
 	token, err := secret.TokenID()
 	if err != nil {
-		jww.FATAL.Fatalf("Could not extract Vault Token: %v", err)
+		return nil, nil, fmt.Errorf("could not extract Vault token: %w", err)
 	}
 
 	client.SetToken(token)
@@ -129,13 +172,44 @@ func performTokenAuth(cfg *api.Config, vaultToken string) (*api.Client, *api.Sec
 	return client, secret, nil
 }
 
+// ErrNoAuthMethodConfigured is returned by authenticateToVault when none of
+// the lease file, --vault-token, VAULT_TOKEN, the token ConfigMap, or a
+// Kubernetes auth role yielded a way to authenticate.
+var ErrNoAuthMethodConfigured = errors.New("no authentication mechanism configured")
+
 // Authenticate to the Vault server.
 // 1. Use the token from the leases file if exists.
 // 2. Use the token from --vault-token (if used)
 // 3. Use VAULT_TOKEN if set.
-// 4. Use K8s ServiceAccountToken against the k8s auth backend if specified.
+// 4. Use the token stored in the configured ConfigMap, if running in Kubernetes.
+// 5. Use K8s ServiceAccountToken against the k8s auth backend if specified.
+//
+// A method selected via --vault-auth-method takes precedence over all of the
+// above, and --force-reauth with a Kubernetes auth role goes straight to 5.
+//
+// Every failure path returns a typed error instead of calling jww.FATAL, so
+// callers running outside of a context where Fatalf-ing the whole process
+// is appropriate (e.g. falling through to another auth method) can handle
+// it themselves.
 func authenticateToVault() (*api.Client, *api.Secret, error) {
 
+	// If an explicit auth method was requested via --vault-auth-method or
+	// VAULT_AUTH_METHOD, use it instead of the legacy auto-detection below.
+	authenticator, err := selectAuthenticator()
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid Vault auth method configuration: %w", err)
+	}
+	if authenticator != nil {
+		jww.INFO.Printf("Authenticating to Vault using explicitly configured method %q", *vaultAuthMethod)
+		return authenticator.Authenticate(context.Background())
+	}
+
+	// --force-reauth must not reuse the lease file's token below; go straight
+	// to a fresh Kubernetes login.
+	if *forceReauth && *k8sAuthRole != "" {
+		return cachedKubernetesAuth()
+	}
+
 	// If there is a leases token, use it.
 	if leases.AuthTokenLease.Token != "" {
 
@@ -146,7 +220,7 @@ func authenticateToVault() (*api.Client, *api.Secret, error) {
 		client, secret, err := performTokenAuth(cfg, leases.AuthTokenLease.Token)
 
 		if err != nil {
-			jww.FATAL.Fatalf("Failed to authenticate to vault server %q with token in lease file. Leases will not be renewed. Error: %v",
+			return nil, nil, fmt.Errorf("failed to authenticate to vault server %q with token in lease file, leases will not be renewed: %w",
 				cfg.Address, err)
 		}
 
@@ -164,7 +238,7 @@ func authenticateToVault() (*api.Client, *api.Secret, error) {
 
 		client, secret, err := performTokenAuth(cfg, *vaultTokenArg)
 		if err != nil {
-			jww.FATAL.Fatalf("Failed to authenticate to Vault Server %q using command line token: %v", cfg.Address, err)
+			return nil, nil, fmt.Errorf("failed to authenticate to Vault server %q using command line token: %w", cfg.Address, err)
 		}
 		return client, secret, nil
 	}
@@ -182,56 +256,81 @@ func authenticateToVault() (*api.Client, *api.Secret, error) {
 
 		client, secret, err := performTokenAuth(cfg, vaultToken)
 		if err != nil {
-			jww.FATAL.Fatalf("Failed to authenticate to Vault Server %q using %q: %v", cfg.Address,
+			return nil, nil, fmt.Errorf("failed to authenticate to Vault server %q using %q: %w", cfg.Address,
 				api.EnvVaultToken, err)
 		}
 		return client, secret, nil
 	}
 
-	// Otherwise, if there is a ConfigMap named vault-token in the default namespace, use the token it stores
+	// Otherwise, if there is a ConfigMap storing a token, use it.
+
+	client, secret, err := authenticateWithTokenConfigMap()
+	if err != nil && !errors.Is(err, rest.ErrNotInCluster) {
+		jww.DEBUG.Printf("Could not authenticate using the token ConfigMap: %v", err)
+	}
+	if client != nil {
+		return client, secret, nil
+	}
+
+	// Lastly, if there's a Kubernetes Auth Role setup, use that...
+
+	if *k8sAuthRole != "" {
+		return cachedKubernetesAuth()
+	}
+
+	return nil, nil, fmt.Errorf("%w: %q is not set and no --vault-auth-method, --vault-token, ConfigMap, or Kubernetes auth role is configured",
+		ErrNoAuthMethodConfigured, api.EnvVaultToken)
+}
 
+// authenticateWithTokenConfigMap looks for a Vault token in a Kubernetes
+// ConfigMap and authenticates with it if found. Its namespace, name, and
+// data key are all configurable via flags so it can be pointed at something
+// other than the default/vault-token/token trio. It returns a nil client
+// and a non-nil error whenever no token can be used from there; when running
+// outside of Kubernetes that error is rest.ErrNotInCluster.
+func authenticateWithTokenConfigMap() (*api.Client, *api.Secret, error) {
 	config, err := rest.InClusterConfig()
-	// If we cannot create the in cluster config, that means we are not running inside of Kubernetes
 	if err != nil {
-		jww.DEBUG.Print("Could not create cluster config - this will fail if this is running outside of Kubernetes")
-	} else {
+		// Propagate the real error so callers only treat "not running
+		// inside Kubernetes" as expected; a genuine in-cluster
+		// misconfiguration (e.g. an unreadable service account token) must
+		// still surface.
+		return nil, nil, err
+	}
 
-		clientset, err := kubernetes.NewForConfig(config)
-		if err != nil {
-			jww.DEBUG.Print("Could not create clientset to call Kubernetes API")
-		} else {
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not create clientset to call Kubernetes API: %w", err)
+	}
 
-			configMaps, err := clientset.CoreV1().ConfigMaps("default").List(v1.ListOptions{FieldSelector: "metadata.name=vault-token"})
-			if err != nil {
-				jww.DEBUG.Printf("Failed to get configmaps filtered on the name vault-token: %v", err)
-			} else if len(configMaps.Items) == 1 {
-				if token, exists := configMaps.Items[0].Data["token"]; exists {
-					// DefaultConfig will digest VAULT_ environment variables
-					cfg := api.DefaultConfig()
-
-					jww.INFO.Printf("Logging into Vault server %q with token from vault-token ConfigMap.", cfg.Address)
-
-					client, secret, err := performTokenAuth(cfg, token)
-					if err != nil {
-						jww.FATAL.Fatalf("Failed to authenticate to Vault Server %q using token from vault-token ConfigMap: %v", cfg.Address, err)
-					}
-					return client, secret, nil
-				}
-			} else {
-				jww.DEBUG.Print("Damn, multiple configmaps were returned when filtering configmaps with the name vault-token. How did this even happen?")
-			}
-		}
+	configMaps, err := clientset.CoreV1().ConfigMaps(*vaultTokenConfigMapNamespace).
+		List(v1.ListOptions{FieldSelector: "metadata.name=" + *vaultTokenConfigMapName})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get configmaps filtered on the name %q: %w", *vaultTokenConfigMapName, err)
 	}
 
-	// Lastly, if there's a Kubernetes Auth Role setup, use that...
+	if len(configMaps.Items) != 1 {
+		return nil, nil, fmt.Errorf("expected exactly one configmap named %q in namespace %q, found %d",
+			*vaultTokenConfigMapName, *vaultTokenConfigMapNamespace, len(configMaps.Items))
+	}
 
-	if *k8sAuthRole != "" {
-		client, secret, err := performKubernetesAuth()
-		return client, secret, err
+	token, exists := configMaps.Items[0].Data[*vaultTokenConfigMapKey]
+	if !exists {
+		return nil, nil, fmt.Errorf("configmap %q has no data key %q", *vaultTokenConfigMapName, *vaultTokenConfigMapKey)
+	}
+
+	// DefaultConfig will digest VAULT_ environment variables
+	cfg := api.DefaultConfig()
+
+	jww.INFO.Printf("Logging into Vault server %q with token from %q ConfigMap.", cfg.Address, *vaultTokenConfigMapName)
+
+	client, secret, err := performTokenAuth(cfg, token)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to authenticate to Vault server %q using token from %q ConfigMap: %w",
+			cfg.Address, *vaultTokenConfigMapName, err)
 	}
 
-	jww.FATAL.Fatalf("No authentication mechanism specified and %q is not set.", api.EnvVaultToken)
-	return nil, nil, nil
+	return client, secret, nil
 }
 
 func readKVSecrets(client *api.Client) map[string]api.Secret {
@@ -257,7 +356,7 @@ func readKVSecrets(client *api.Client) map[string]api.Secret {
 		}
 
 		jww.DEBUG.Printf("Reading secrets from %q", path)
-		response, err := client.Logical().Read(path)
+		response, err := readSecret(client, path, request.KVVersion, request.Version)
 
 		if err != nil {
 			jww.FATAL.Fatalf("error fetching secret %q from %q: %v", path, client.Address(), err)
@@ -273,6 +372,15 @@ func readKVSecrets(client *api.Client) map[string]api.Secret {
 			}
 		} else {
 			enrollSecretInLease(response)
+
+			// If a background scheduler is running, hand the lease off to
+			// it so it gets renewed proactively (and callers get a
+			// RenewalFailed/RenewalExpired event to re-fetch on) instead of
+			// only being renewed on the next one-shot invocation.
+			if activeScheduler != nil && response.Renewable && response.LeaseID != "" {
+				activeScheduler.AddLease(response.LeaseID, false, time.Duration(response.LeaseDuration)*time.Second)
+			}
+
 			vaultSecretsMapping[key] = *response
 		}
 	}